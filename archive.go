@@ -0,0 +1,262 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archiveEntry describes one file inside an uploaded archive, as returned
+// by listHandler.
+type archiveEntry struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+// archiveKind identifies which container format a blob's extension names.
+type archiveKind int
+
+const (
+	notArchive archiveKind = iota
+	archiveZip
+	archiveTarGz
+)
+
+// archiveKindForExt classifies a stored blob's extension (as produced by
+// fileExtOf) so list/download handlers know how to read it.
+func archiveKindForExt(ext string) archiveKind {
+	switch strings.ToLower(ext) {
+	case ".zip":
+		return archiveZip
+	case ".tar.gz", ".tgz":
+		return archiveTarGz
+	default:
+		return notArchive
+	}
+}
+
+// fileExtOf returns the extension SimpleHost stores a blob under,
+// preserving the ".tar.gz" compound extension instead of truncating it to
+// ".gz" the way filepath.Ext would.
+func fileExtOf(name string) string {
+	if strings.HasSuffix(strings.ToLower(name), ".tar.gz") {
+		return name[len(name)-len(".tar.gz"):]
+	}
+	return filepath.Ext(name)
+}
+
+// listHandler responds to GET /list?file=<token> with a JSON manifest of
+// an uploaded archive's entries.
+func (s *Server) listHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("file")
+	if token == "" {
+		http.Error(w, "File name is required", http.StatusBadRequest)
+		return
+	}
+
+	rec, exists := s.meta.Get(token)
+	if !exists || time.Now().After(rec.ExpiresAt) {
+		http.Error(w, "File not found or expired", http.StatusNotFound)
+		return
+	}
+
+	if rec.PasswordHash != "" && hashPassword(r.URL.Query().Get("password")) != rec.PasswordHash {
+		http.Error(w, "Invalid or missing password", http.StatusUnauthorized)
+		return
+	}
+
+	kind := archiveKindForExt(rec.Ext)
+	if kind == notArchive {
+		http.Error(w, "File is not a supported archive", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := s.listArchiveEntries(rec, kind)
+	if err != nil {
+		http.Error(w, "Unable to read archive", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// listArchiveEntries opens rec's blob and indexes its entries without
+// extracting them to disk.
+func (s *Server) listArchiveEntries(rec fileRecord, kind archiveKind) ([]archiveEntry, error) {
+	file, size, err := s.store.Get(rec.blobName())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch kind {
+	case archiveZip:
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), size)
+		if err != nil {
+			return nil, err
+		}
+
+		entries := make([]archiveEntry, 0, len(zr.File))
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			entries = append(entries, archiveEntry{
+				Path:     f.Name,
+				Size:     int64(f.UncompressedSize64),
+				MimeType: mimeForName(f.Name),
+			})
+		}
+		return entries, nil
+
+	case archiveTarGz:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+
+		var entries []archiveEntry
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			entries = append(entries, archiveEntry{
+				Path:     hdr.Name,
+				Size:     hdr.Size,
+				MimeType: mimeForName(hdr.Name),
+			})
+		}
+		return entries, nil
+
+	default:
+		return nil, errors.New("unsupported archive kind")
+	}
+}
+
+// downloadArchiveEntry streams a single base64url-encoded entry path out
+// of rec's archive, without extracting the rest of the bundle.
+func (s *Server) downloadArchiveEntry(w http.ResponseWriter, rec fileRecord, encodedEntry string) {
+	entryPath, err := base64.URLEncoding.DecodeString(encodedEntry)
+	if err != nil {
+		http.Error(w, "Invalid entry", http.StatusBadRequest)
+		return
+	}
+
+	kind := archiveKindForExt(rec.Ext)
+	if kind == notArchive {
+		http.Error(w, "File is not a supported archive", http.StatusBadRequest)
+		return
+	}
+
+	file, size, err := s.store.Get(rec.blobName())
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	// The file handle above is already open, so even if this is the
+	// download that reaches MaxDownloads and recordDownload deletes the
+	// underlying blob, this entry still streams to completion.
+	if s.startsNewDownload(rec.Token) {
+		s.recordDownload(rec.Token)
+	}
+
+	switch kind {
+	case archiveZip:
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Unable to read archive", http.StatusInternalServerError)
+			return
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), size)
+		if err != nil {
+			http.Error(w, "Unable to read archive", http.StatusInternalServerError)
+			return
+		}
+
+		for _, f := range zr.File {
+			if f.Name != string(entryPath) {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				http.Error(w, "Unable to read entry", http.StatusInternalServerError)
+				return
+			}
+			defer rc.Close()
+
+			writeEntryHeaders(w, f.Name, int64(f.UncompressedSize64))
+			io.Copy(w, rc)
+			return
+		}
+
+	case archiveTarGz:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			http.Error(w, "Unable to read archive", http.StatusInternalServerError)
+			return
+		}
+		defer gz.Close()
+
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, "Unable to read archive", http.StatusInternalServerError)
+				return
+			}
+			if hdr.Name != string(entryPath) {
+				continue
+			}
+
+			writeEntryHeaders(w, hdr.Name, hdr.Size)
+			io.Copy(w, tr)
+			return
+		}
+	}
+
+	http.Error(w, "Entry not found", http.StatusNotFound)
+}
+
+func writeEntryHeaders(w http.ResponseWriter, name string, size int64) {
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(name)))
+	w.Header().Set("Content-Type", mimeForName(name))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+}
+
+func mimeForName(name string) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
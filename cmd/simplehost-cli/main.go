@@ -0,0 +1,82 @@
+// Command simplehost-cli uploads a file to a SimpleHost server and prints
+// the resulting download URL, making it easy to script uploads from a
+// shell (or wire up to a ShareX custom uploader).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	serverURL := flag.String("url", "http://localhost:8080", "SimpleHost server base URL")
+	expiry := flag.String("expiry", "", "how long the upload stays downloadable (e.g. 24h), server default if empty")
+	maxDownloads := flag.Int("max-downloads", 0, "delete the upload after this many downloads, 0 for unlimited")
+	password := flag.String("password", "", "require this password to download the file")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: simplehost-cli [flags] <file>")
+		os.Exit(1)
+	}
+
+	if err := upload(*serverURL, flag.Arg(0), *expiry, *maxDownloads, *password); err != nil {
+		fmt.Fprintf(os.Stderr, "simplehost-cli: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func upload(serverURL, path, expiry string, maxDownloads int, password string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if expiry != "" {
+		query.Set("expiry", expiry)
+	}
+	if maxDownloads > 0 {
+		query.Set("maxDownloads", fmt.Sprintf("%d", maxDownloads))
+	}
+	if password != "" {
+		query.Set("password", password)
+	}
+
+	uploadURL := fmt.Sprintf("%s/upload/%s?%s", serverURL, url.PathEscape(filepath.Base(path)), query.Encode())
+
+	req, err := http.NewRequest(http.MethodPut, uploadURL, file)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s: %s", resp.Status, body)
+	}
+
+	os.Stdout.Write(body)
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	meta, err := openMetadataStore(filepath.Join(t.TempDir(), "metadata.json"))
+	if err != nil {
+		t.Fatalf("openMetadataStore: %v", err)
+	}
+	return NewServer(newFakeStorage(), meta)
+}
+
+// TestDownloadHandlerRangeRequestStillCountsAgainstMaxDownloads guards
+// against the bypass where a request with a Range header that doesn't
+// start at byte 0 never counted against MaxDownloads at all: a client
+// could fetch a maxDownloads=1 token an unlimited number of times just by
+// always sending e.g. "Range: bytes=1-".
+func TestDownloadHandlerRangeRequestStillCountsAgainstMaxDownloads(t *testing.T) {
+	s := newTestServer(t)
+
+	token, err := s.storeUpload(strings.NewReader("hello world"), ".txt", time.Hour, 1, "")
+	if err != nil {
+		t.Fatalf("storeUpload: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/download?file="+token, nil)
+	req.Header.Set("Range", "bytes=1-")
+	rec := httptest.NewRecorder()
+	s.downloadHandler(rec, req)
+	if rec.Code != http.StatusOK && rec.Code != http.StatusPartialContent {
+		t.Fatalf("first download (Range: bytes=1-) status = %d, want 200 or 206", rec.Code)
+	}
+
+	req2 := httptest.NewRequest(http.MethodGet, "/download?file="+token, nil)
+	rec2 := httptest.NewRecorder()
+	s.downloadHandler(rec2, req2)
+	if rec2.Code != http.StatusNotFound {
+		t.Fatalf("second download status = %d, want 404 (token should be gone after the single allowed download)", rec2.Code)
+	}
+}
+
+// TestDownloadHandlerRangeBurstCountsAsOneDownload is the legitimate
+// counterpart of the test above: a burst of Range requests close together
+// in time (a media player seeking) must not each consume a separate slot
+// of MaxDownloads.
+func TestDownloadHandlerRangeBurstCountsAsOneDownload(t *testing.T) {
+	s := newTestServer(t)
+
+	token, err := s.storeUpload(strings.NewReader("hello world, this is file content"), ".txt", time.Hour, 2, "")
+	if err != nil {
+		t.Fatalf("storeUpload: %v", err)
+	}
+
+	for _, rng := range []string{"bytes=0-4", "bytes=5-10", "bytes=11-"} {
+		req := httptest.NewRequest(http.MethodGet, "/download?file="+token, nil)
+		req.Header.Set("Range", rng)
+		rec := httptest.NewRecorder()
+		s.downloadHandler(rec, req)
+		if rec.Code != http.StatusOK && rec.Code != http.StatusPartialContent {
+			t.Fatalf("Range %q status = %d, want 200 or 206", rng, rec.Code)
+		}
+	}
+
+	got, ok := s.meta.Get(token)
+	if !ok {
+		t.Fatalf("token deleted after a 3-request Range burst, want it to still exist (maxDownloads=2)")
+	}
+	if got.DownloadCount != 1 {
+		t.Fatalf("DownloadCount = %d after one Range burst, want 1", got.DownloadCount)
+	}
+}
+
+// TestDownloadHandlerArchiveEntryCountsAgainstMaxDownloads guards against
+// the bypass where /download?file=X&entry=Y returned before the
+// MaxDownloads check and before recordDownload was ever called, so every
+// entry of an archive could be downloaded without limit.
+func TestDownloadHandlerArchiveEntryCountsAgainstMaxDownloads(t *testing.T) {
+	s := newTestServer(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fw, err := zw.Create("hello.txt")
+	if err != nil {
+		t.Fatalf("zip Create: %v", err)
+	}
+	if _, err := fw.Write([]byte("entry contents")); err != nil {
+		t.Fatalf("zip Write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip Close: %v", err)
+	}
+
+	token, err := s.storeUpload(bytes.NewReader(buf.Bytes()), ".zip", time.Hour, 1, "")
+	if err != nil {
+		t.Fatalf("storeUpload: %v", err)
+	}
+
+	entry := base64.URLEncoding.EncodeToString([]byte("hello.txt"))
+
+	req := httptest.NewRequest(http.MethodGet, "/download?file="+token+"&entry="+entry, nil)
+	rec := httptest.NewRecorder()
+	s.downloadHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first entry download status = %d, want 200", rec.Code)
+	}
+
+	req2 := httptest.NewRequest(http.MethodGet, "/download?file="+token+"&entry="+entry, nil)
+	rec2 := httptest.NewRecorder()
+	s.downloadHandler(rec2, req2)
+	if rec2.Code != http.StatusNotFound {
+		t.Fatalf("second entry download status = %d, want 404 (token should be gone after the single allowed download)", rec2.Code)
+	}
+}
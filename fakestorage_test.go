@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/Isske/SimpleHost/storage"
+)
+
+// fakeStorage is an in-memory storage.Storage used by handler-level tests,
+// standing in for the local/S3/GCS backends under test.
+type fakeStorage struct {
+	mu   sync.Mutex
+	data map[string][]byte
+
+	// failRenameOnce, if non-empty, fails exactly one Rename call whose
+	// oldName matches and then clears itself, so tests can simulate a
+	// transient storage error on one racing upload's rename.
+	failRenameOnce map[string]bool
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{data: make(map[string][]byte)}
+}
+
+func (f *fakeStorage) Put(name string, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data[name] = data
+	return int64(len(data)), nil
+}
+
+func (f *fakeStorage) Get(name string) (io.ReadCloser, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, ok := f.data[name]
+	if !ok {
+		return nil, 0, storage.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+func (f *fakeStorage) Delete(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.data[name]; !ok {
+		return storage.ErrNotExist
+	}
+	delete(f.data, name)
+	return nil
+}
+
+func (f *fakeStorage) Stat(name string) (storage.Info, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, ok := f.data[name]
+	if !ok {
+		return storage.Info{}, storage.ErrNotExist
+	}
+	return storage.Info{Name: name, Size: int64(len(data))}, nil
+}
+
+func (f *fakeStorage) Rename(oldName, newName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failRenameOnce[oldName] {
+		delete(f.failRenameOnce, oldName)
+		return errors.New("fakeStorage: simulated rename failure")
+	}
+
+	data, ok := f.data[oldName]
+	if !ok {
+		return storage.ErrNotExist
+	}
+	f.data[newName] = data
+	delete(f.data, oldName)
+	return nil
+}
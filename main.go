@@ -1,30 +1,156 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/Isske/SimpleHost/storage"
+)
+
+// defaultMaxFileSize and defaultStorageQuota bound a Server that hasn't had
+// its MaxFileSize/StorageQuota fields overridden.
+const (
+	defaultMaxFileSize  = 1 << 30  // 1 GiB
+	defaultStorageQuota = 50 << 30 // 50 GiB
+
+	// defaultExpiry is how long an upload stays downloadable when the
+	// caller doesn't request a different expiry.
+	defaultExpiry = 60 * time.Minute
+
+	// janitorInterval is how often the backstop sweep runs, catching any
+	// expired file whose time.AfterFunc was lost to a restart.
+	janitorInterval = time.Minute
+
+	// downloadSessionWindow bounds how long a burst of requests for the
+	// same token (a media player's Range requests while seeking, a client
+	// resuming a partial transfer) counts as a single logical download
+	// instead of one per HTTP request.
+	downloadSessionWindow = 30 * time.Second
+)
+
+var (
+	errFileTooLarge  = errors.New("file exceeds the maximum allowed size")
+	errQuotaExceeded = errors.New("storage quota would be exceeded")
 )
 
+// httpError pairs an error message with the HTTP status it should be
+// reported with, so storeUpload can be shared by handlers that render HTML
+// and ones that render plain text.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	var herr *httpError
+	if errors.As(err, &herr) {
+		http.Error(w, herr.msg, herr.status)
+		return
+	}
+	http.Error(w, "Unable to save the file", http.StatusInternalServerError)
+}
+
 type Server struct {
-	storagePath string
-	files       map[string]time.Time
-	mu          sync.Mutex
+	store storage.Storage
+	meta  *metadataStore
+
+	// MaxFileSize is the largest single upload the server will accept, in
+	// bytes. Uploads exceeding it are aborted mid-stream.
+	MaxFileSize int64
+	// StorageQuota is the total number of bytes the server will keep on
+	// disk across all uploads at once.
+	StorageQuota int64
+	// usedBytes is the running total of bytes-on-disk, tracked atomically
+	// so storeUpload and quotaReader can check it without a lock.
+	usedBytes int64
+
+	// activeDownloads tracks, per token, the last time a byte of it was
+	// served, so startsNewDownload can tell a burst of requests for the
+	// same token apart from a genuinely new download.
+	activeDownloadsMu sync.Mutex
+	activeDownloads   map[string]time.Time
+}
+
+// NewServer wires up a Server backed by store and meta, rehydrating any
+// files left over from a previous run: expired ones are deleted, the rest
+// get their expiry timers re-armed and count against usedBytes.
+func NewServer(store storage.Storage, meta *metadataStore) *Server {
+	s := &Server{
+		store:           store,
+		meta:            meta,
+		MaxFileSize:     defaultMaxFileSize,
+		StorageQuota:    defaultStorageQuota,
+		activeDownloads: make(map[string]time.Time),
+	}
+
+	s.rehydrate()
+	go s.janitorLoop()
+
+	return s
+}
+
+// rehydrate restores in-memory state (usedBytes, expiry timers) from the
+// metadata store on startup. usedBytes only counts each distinct blob
+// once, even if several tokens share it.
+func (s *Server) rehydrate() {
+	now := time.Now()
+	counted := make(map[string]bool)
+	var used int64
+	for _, rec := range s.meta.All() {
+		if now.After(rec.ExpiresAt) {
+			s.deleteFile(rec.Token)
+			continue
+		}
+		if !counted[rec.blobName()] {
+			used += rec.Size
+			counted[rec.blobName()] = true
+		}
+		s.armExpiry(rec.Token, rec.ExpiresAt.Sub(now))
+	}
+	// deleteFile above subtracts the size of every expired blob it removes,
+	// so the live total is stored only once the loop is done.
+	atomic.StoreInt64(&s.usedBytes, used)
+}
+
+func (s *Server) armExpiry(token string, d time.Duration) {
+	time.AfterFunc(d, func() {
+		s.deleteFile(token)
+	})
 }
 
-func NewServer(storagePath string) *Server {
-	return &Server{
-		storagePath: storagePath,
-		files:       make(map[string]time.Time),
+// janitorLoop is a backstop against lost timers (e.g. a restart that
+// happens to race a rehydrate): it periodically sweeps the metadata store
+// for anything that has expired but wasn't yet cleaned up.
+func (s *Server) janitorLoop() {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		for _, rec := range s.meta.All() {
+			if now.After(rec.ExpiresAt) {
+				s.deleteFile(rec.Token)
+			}
+		}
 	}
 }
 
-func (s *Server) uploadPageHandler(w http.ResponseWriter) {
-	html := `
+func (s *Server) uploadPageHandler(w http.ResponseWriter, r *http.Request) {
+	html := fmt.Sprintf(`
 		<!DOCTYPE html>
 		<html lang="en">
 		<head>
@@ -44,66 +170,58 @@ func (s *Server) uploadPageHandler(w http.ResponseWriter) {
 		</style>
 		<body>
 			<h1>Upload File</h1>
-			<p>Max Upload Size : 10MB
+			<p>Max Upload Size : %s
 			<form enctype="multipart/form-data" action="/upload" method="post">
 				<input type="file" name="file" required>
 				<input type="submit" value="Upload">
 			</form>
 		</body>
 		</html>
-	`
+	`, humanSize(s.MaxFileSize))
 
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(html))
 }
 
-func (s *Server) uploadHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
-		return
+// humanSize formats n bytes as a short binary-unit size (KiB/MiB/GiB, ...),
+// used to show the server's configured MaxFileSize on the upload page.
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
 	}
 
-	err := r.ParseMultipartForm(10 << 20) // 10 MB max file size
-	if err != nil {
-		http.Error(w, "Unable to parse form", http.StatusBadRequest)
-		return
+	div, exp := int64(unit), 0
+	for x := n / unit; x >= unit; x /= unit {
+		div *= unit
+		exp++
 	}
+	return fmt.Sprintf("%.0f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
 
-	file, handler, err := r.FormFile("file")
+func (s *Server) uploadHandler(w http.ResponseWriter, r *http.Request) {
+	mr, err := r.MultipartReader()
 	if err != nil {
-		http.Error(w, "Unable to get the file from form", http.StatusBadRequest)
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
-
-	fileExtension := filepath.Ext(handler.Filename)
-	newFileName := fmt.Sprintf("%s-%d%s", "simplehost", time.Now().Unix(), fileExtension)
-	filePath := filepath.Join(s.storagePath, newFileName)
 
-	out, err := os.Create(filePath)
+	part, err := nextFilePart(mr)
 	if err != nil {
-		http.Error(w, "Unable to create the file on server", http.StatusInternalServerError)
+		http.Error(w, "Unable to get the file from form", http.StatusBadRequest)
 		return
 	}
-	defer out.Close()
+	defer part.Close()
 
-	_, err = io.Copy(out, file)
+	token, err := s.storeUpload(part, fileExtOf(part.FileName()), defaultExpiry, 0, "")
 	if err != nil {
-		http.Error(w, "Unable to save the file", http.StatusInternalServerError)
+		writeUploadError(w, err)
 		return
 	}
 
-	expiryDuration := 60 * time.Minute
-	s.mu.Lock()
-	s.files[newFileName] = time.Now().Add(expiryDuration)
-	s.mu.Unlock()
-
-	time.AfterFunc(expiryDuration, func() {
-		s.deleteFile(newFileName)
-	})
-
-	downloadLink := fmt.Sprintf("/download?file=%s", newFileName)
+	rec, _ := s.meta.Get(token)
+	downloadLink := fmt.Sprintf("/download?file=%s", token)
 	htmlResponse := fmt.Sprintf(`
 		<!DOCTYPE html>
 		<html lang="en">
@@ -129,86 +247,419 @@ func (s *Server) uploadHandler(w http.ResponseWriter, r *http.Request) {
 			<p><a href="%s">Download</a></p>
 		</body>
 		</html>
-	`, newFileName, downloadLink)
+	`, rec.blobName(), downloadLink)
 
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(htmlResponse))
 }
 
-func (s *Server) downloadHandler(w http.ResponseWriter, r *http.Request) {
-	fileName := r.URL.Query().Get("file")
+// putUploadHandler implements PUT /upload/<filename>, a scriptable
+// counterpart to uploadHandler's browser form: the request body is the
+// file verbatim, and the response is a plain-text download URL, so
+// `curl --upload-file foo.zip https://host/upload/foo.zip` just works.
+// Query parameters let CLI-style clients set expiry, a download cap and a
+// password: ?expiry=24h&maxDownloads=5&password=hunter2.
+func (s *Server) putUploadHandler(w http.ResponseWriter, r *http.Request) {
+	fileName := strings.TrimPrefix(r.URL.Path, "/upload/")
 	if fileName == "" {
 		http.Error(w, "File name is required", http.StatusBadRequest)
 		return
 	}
 
-	s.mu.Lock()
-	expiryTime, exists := s.files[fileName]
-	s.mu.Unlock()
+	expiry := defaultExpiry
+	if v := r.URL.Query().Get("expiry"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			http.Error(w, "Invalid expiry", http.StatusBadRequest)
+			return
+		}
+		expiry = d
+	}
+
+	maxDownloads := 0
+	if v := r.URL.Query().Get("maxDownloads"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid maxDownloads", http.StatusBadRequest)
+			return
+		}
+		maxDownloads = n
+	}
+
+	passwordHash := ""
+	if pw := r.URL.Query().Get("password"); pw != "" {
+		passwordHash = hashPassword(pw)
+	}
+
+	token, err := s.storeUpload(r.Body, fileExtOf(fileName), expiry, maxDownloads, passwordHash)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "%s\n", downloadURL(r, token))
+}
 
-	if !exists || time.Now().After(expiryTime) {
+func downloadURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/download?file=%s", scheme, r.Host, token)
+}
+
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) downloadHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("file")
+	if token == "" {
+		http.Error(w, "File name is required", http.StatusBadRequest)
+		return
+	}
+
+	rec, exists := s.meta.Get(token)
+	if !exists || time.Now().After(rec.ExpiresAt) {
 		http.Error(w, "File not found or expired", http.StatusNotFound)
 		return
 	}
 
-	filePath := filepath.Join(s.storagePath, fileName)
+	if rec.PasswordHash != "" && hashPassword(r.URL.Query().Get("password")) != rec.PasswordHash {
+		http.Error(w, "Invalid or missing password", http.StatusUnauthorized)
+		return
+	}
 
-	file, err := os.Open(filePath)
+	if rec.MaxDownloads > 0 && rec.DownloadCount >= rec.MaxDownloads {
+		http.Error(w, "Maximum downloads exceeded", http.StatusGone)
+		return
+	}
+
+	if entry := r.URL.Query().Get("entry"); entry != "" {
+		s.downloadArchiveEntry(w, rec, entry)
+		return
+	}
+
+	blobName := rec.blobName()
+	file, size, err := s.store.Get(blobName)
 	if err != nil {
 		http.Error(w, "File not found", http.StatusNotFound)
 		return
 	}
 	defer file.Close()
 
-	contentType := "application/octet-stream"
-	fileStat, err := file.Stat()
-	if err == nil {
-		contentType = http.DetectContentType(make([]byte, fileStat.Size()))
+	// The file handle above is already open, so even if this is the
+	// download that reaches MaxDownloads and recordDownload deletes the
+	// underlying blob, this response still streams to completion:
+	// deleting a name doesn't invalidate a file handle already open on it.
+	if s.startsNewDownload(token) {
+		s.recordDownload(token)
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", blobName))
+
+	// Backends whose Get returns a seekable file (the local backend) get
+	// Range support and conditional requests for free from ServeContent.
+	// The content type is sniffed from the first 512 bytes beforehand, so
+	// ServeContent does not fall back to guessing it from the extension.
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		head := make([]byte, 512)
+		n, err := io.ReadFull(seeker, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			http.Error(w, "Unable to read file", http.StatusInternalServerError)
+			return
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			http.Error(w, "Unable to read file", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", http.DetectContentType(head[:n]))
+		http.ServeContent(w, r, blobName, rec.UploadedAt, seeker)
+		return
 	}
 
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	body, contentType, err := sniffContentType(file)
+	if err != nil {
+		http.Error(w, "Unable to read file", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", fileStat.Size()))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+	io.Copy(w, body)
+}
+
+// startsNewDownload reports whether this request begins a new logical
+// download of token rather than continuing one already in progress (e.g.
+// a media player's Range requests while seeking, or a client resuming a
+// partial transfer). It is decided from server-observed request timing,
+// not the client-supplied Range header: a request with no Range header,
+// or one that happens to start at byte 0, isn't proof of anything — a
+// client can set Range to whatever it likes, and a version of this check
+// that trusted "does the range start at 0" let a request with
+// Range: bytes=1- skip counting forever, downloading an unlimited number
+// of times past MaxDownloads.
+func (s *Server) startsNewDownload(token string) bool {
+	now := time.Now()
+
+	s.activeDownloadsMu.Lock()
+	defer s.activeDownloadsMu.Unlock()
+
+	last, seen := s.activeDownloads[token]
+	s.activeDownloads[token] = now
+	return !seen || now.Sub(last) > downloadSessionWindow
+}
+
+// sniffContentType detects a stream's real content type from its first
+// 512 bytes (the http.DetectContentType window) and returns a reader that
+// still yields the full stream, sniffed bytes included.
+func sniffContentType(r io.Reader) (io.Reader, string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, "", err
+	}
+	head = head[:n]
 
-	http.ServeFile(w, r, filePath)
+	return io.MultiReader(bytes.NewReader(head), r), http.DetectContentType(head), nil
 }
 
-func (s *Server) deleteFile(fileName string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// storeUpload streams r into content-addressed storage, deduplicating
+// against any existing blob with the same hash and extension, and returns
+// a fresh share token for it. It is shared by the multipart browser
+// upload and the raw-body PUT endpoint.
+func (s *Server) storeUpload(r io.Reader, ext string, expiry time.Duration, maxDownloads int, passwordHash string) (string, error) {
+	if atomic.LoadInt64(&s.usedBytes) >= s.StorageQuota {
+		return "", &httpError{http.StatusInsufficientStorage, "Storage quota exceeded"}
+	}
+
+	tempName := ".tmp-" + newToken()
+	tracked := &quotaReader{r: r, s: s, max: s.MaxFileSize}
+	hasher := sha256.New()
+	_, putErr := s.store.Put(tempName, io.TeeReader(tracked, hasher))
 
-	filePath := filepath.Join(s.storagePath, fileName)
-	err := os.Remove(filePath)
+	// Roll back this upload's contribution to usedBytes: on success it is
+	// re-added below only if the content is genuinely new, and on failure
+	// none of the partial file's bytes should count against the quota.
+	atomic.AddInt64(&s.usedBytes, -tracked.n)
+
+	if tracked.err != nil {
+		s.store.Delete(tempName)
+		if errors.Is(tracked.err, errFileTooLarge) {
+			return "", &httpError{http.StatusRequestEntityTooLarge, errFileTooLarge.Error()}
+		}
+		return "", &httpError{http.StatusInsufficientStorage, errQuotaExceeded.Error()}
+	}
+	if putErr != nil {
+		s.store.Delete(tempName)
+		return "", &httpError{http.StatusInternalServerError, "Unable to save the file"}
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	blobName := hash + ext
+
+	now := time.Now()
+	rec := fileRecord{
+		Token:        newToken(),
+		Hash:         hash,
+		Ext:          ext,
+		UploadedAt:   now,
+		ExpiresAt:    now.Add(expiry),
+		Size:         tracked.n,
+		MaxDownloads: maxDownloads,
+		PasswordHash: passwordHash,
+	}
+
+	// Reserve rec before touching storage: ReserveRecord's dedup check and
+	// its insert happen under one lock, so rec already counts toward
+	// BlobRefCount(blobName) the instant this call returns. That closes the
+	// window a plain FindByHash-then-Put would leave open, where the last
+	// other token referencing blobName could expire and be swept between
+	// the two, deleting the blob before rec ever got persisted.
+	isDuplicate, err := s.meta.ReserveRecord(rec)
 	if err != nil {
-		fmt.Printf("Error deleting file: %s\n", err)
+		fmt.Printf("Error persisting metadata for %s: %s\n", rec.Token, err)
+	}
+
+	// Deduplicate: if this content is already stored, share that blob
+	// instead of adding a second copy to the quota. Every upload still gets
+	// its own token and expiry, even when the blob is shared.
+	if !isDuplicate {
+		atomic.AddInt64(&s.usedBytes, rec.Size)
+	}
+
+	// Rename this upload's own temp copy into place even when isDuplicate
+	// is true: the other uploader racing us to the same hash+ext reserved
+	// first, but nothing guarantees its Rename actually succeeds (a
+	// transient storage error can still fail it after we've already
+	// decided to trust it). Every racer's temp copy has identical content,
+	// so renaming onto an already-materialized blobName is a harmless
+	// no-op overwrite, not a fresh write — and it's the only way to
+	// guarantee blobName exists before this handler responds with rec's
+	// token.
+	if err := s.store.Rename(tempName, blobName); err != nil {
+		s.store.Delete(tempName)
+		s.meta.Delete(rec.Token)
+		if !isDuplicate {
+			atomic.AddInt64(&s.usedBytes, -rec.Size)
+		}
+		return "", &httpError{http.StatusInternalServerError, "Unable to save the file"}
+	}
+
+	s.armExpiry(rec.Token, expiry)
+
+	return rec.Token, nil
+}
+
+// recordDownload increments a token's download count and, once
+// MaxDownloads is reached, deletes it early instead of waiting for its
+// normal expiry.
+func (s *Server) recordDownload(token string) {
+	rec, ok, err := s.meta.IncrementDownloadCount(token)
+	if !ok {
 		return
 	}
+	if err != nil {
+		fmt.Printf("Error recording download for %s: %s\n", token, err)
+	}
 
-	delete(s.files, fileName)
-	fmt.Printf("File deleted: %s\n", fileName)
+	if rec.MaxDownloads > 0 && rec.DownloadCount >= rec.MaxDownloads {
+		s.deleteFile(token)
+	}
 }
 
-func main() {
-	storagePath := "./uploads"
-	os.MkdirAll(storagePath, os.ModePerm)
+// newToken returns a random, unguessable share token.
+func newToken() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err) // crypto/rand failing means the system RNG is broken
+	}
+	return hex.EncodeToString(buf)
+}
 
-	server := NewServer(storagePath)
+// deleteFile removes the share token and, if no other token still
+// references the same blob, the underlying stored file too.
+func (s *Server) deleteFile(token string) {
+	rec, ok := s.meta.Get(token)
+	if !ok {
+		return
+	}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		server.uploadPageHandler(w)
-	})
+	s.meta.Delete(token)
+
+	s.activeDownloadsMu.Lock()
+	delete(s.activeDownloads, token)
+	s.activeDownloadsMu.Unlock()
+
+	blobName := rec.blobName()
+	if s.meta.BlobRefCount(blobName) > 0 {
+		return
+	}
+
+	if err := s.store.Delete(blobName); err != nil {
+		fmt.Printf("Error deleting file: %s\n", err)
+		return
+	}
+
+	atomic.AddInt64(&s.usedBytes, -rec.Size)
+	fmt.Printf("File deleted: %s\n", blobName)
+}
 
-	http.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "GET" {
-			http.Error(w, "Route Doesn't Exist :/", http.StatusNotFound)
-		} else if r.Method == "POST" {
-			server.uploadHandler(w, r)
+// nextFilePart scans a multipart request for the first part named "file",
+// skipping any other form fields.
+func nextFilePart(mr *multipart.Reader) (*multipart.Part, error) {
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, err
 		}
-	})
+		if part.FormName() == "file" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
 
-	http.HandleFunc("/download", server.downloadHandler)
+// quotaReader wraps a multipart part, enforcing a per-file size limit and
+// the server's global storage quota as bytes are streamed through it, so
+// oversized or over-quota uploads are caught mid-stream instead of after
+// buffering the whole body.
+type quotaReader struct {
+	r   io.Reader
+	s   *Server
+	max int64
+
+	n   int64
+	err error
+}
+
+func (q *quotaReader) Read(p []byte) (int, error) {
+	n, err := q.r.Read(p)
+	if n > 0 {
+		q.n += int64(n)
+		used := atomic.AddInt64(&q.s.usedBytes, int64(n))
+		if q.n > q.max {
+			q.err = errFileTooLarge
+			return n, q.err
+		}
+		if used > q.s.StorageQuota {
+			q.err = errQuotaExceeded
+			return n, q.err
+		}
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+func main() {
+	store, err := storage.NewFromEnv()
+	if err != nil {
+		fmt.Printf("Unable to initialize storage backend: %s\n", err)
+		return
+	}
+
+	metadataPath := envOr("METADATA_FILE", "./simplehost-metadata.json")
+	meta, err := openMetadataStore(metadataPath)
+	if err != nil {
+		fmt.Printf("Unable to load metadata store: %s\n", err)
+		return
+	}
+
+	server := NewServer(store, meta)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", server.uploadPageHandler)
+	mux.Handle("/upload", methodHandler{http.MethodPost: server.uploadHandler})
+	mux.Handle("/upload/", methodHandler{http.MethodPut: server.putUploadHandler})
+	mux.HandleFunc("/download", server.downloadHandler)
+	mux.HandleFunc("/list", server.listHandler)
 
 	fmt.Println("Server is running on port 8080")
-	http.ListenAndServe(":8080", nil)
+	http.ListenAndServe(":8080", mux)
+}
+
+// methodHandler dispatches a request to the handler registered for its
+// HTTP method, replying 405 for any method that isn't.
+type methodHandler map[string]http.HandlerFunc
+
+func (m methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler, ok := m[r.Method]
+	if !ok {
+		w.Header().Set("Allow", strings.Join(allowedMethods(m), ", "))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	handler(w, r)
+}
+
+func allowedMethods(m methodHandler) []string {
+	methods := make([]string, 0, len(m))
+	for method := range m {
+		methods = append(methods, method)
+	}
+	return methods
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// envOr returns the value of the named environment variable, or fallback
+// if it is unset or empty.
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// fileRecord is the persisted metadata for one share token. It is written
+// to disk so uploads survive a process restart. The underlying blob is
+// named after Hash+Ext and may be shared by several records when the same
+// content is uploaded more than once.
+type fileRecord struct {
+	Token         string    `json:"token"`
+	Hash          string    `json:"hash"`
+	Ext           string    `json:"ext"`
+	UploadedAt    time.Time `json:"uploadedAt"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+	Size          int64     `json:"size"`
+	DownloadCount int       `json:"downloadCount"`
+	MaxDownloads  int       `json:"maxDownloads"`
+	PasswordHash  string    `json:"passwordHash,omitempty"`
+}
+
+// blobName is the content-addressed storage key for the record's blob.
+func (r fileRecord) blobName() string {
+	return r.Hash + r.Ext
+}
+
+// metadataStore persists fileRecords to a JSON file on every mutation. It
+// is safe for concurrent use.
+type metadataStore struct {
+	path string
+
+	mu      sync.Mutex
+	records map[string]fileRecord
+}
+
+// openMetadataStore loads path if it exists, or starts with an empty store
+// when it doesn't.
+func openMetadataStore(path string) (*metadataStore, error) {
+	store := &metadataStore{path: path, records: make(map[string]fileRecord)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, err
+	}
+	if len(data) == 0 {
+		return store, nil
+	}
+	if err := json.Unmarshal(data, &store.records); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Put upserts rec and persists the store.
+func (m *metadataStore) Put(rec fileRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records[rec.Token] = rec
+	return m.saveLocked()
+}
+
+// Get returns the record for token, if any.
+func (m *metadataStore) Get(token string) (fileRecord, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.records[token]
+	return rec, ok
+}
+
+// Delete removes token and persists the store.
+func (m *metadataStore) Delete(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, token)
+	return m.saveLocked()
+}
+
+// IncrementDownloadCount atomically increments token's download count and
+// persists the result, returning the updated record. Doing the
+// get-increment-put under one lock (rather than composing Get and Put)
+// keeps concurrent downloads of the same token from each reading the same
+// count and writing back the same +1, which would let a token with
+// MaxDownloads=1 be fetched more than once. ok is false if token is not
+// known.
+func (m *metadataStore) IncrementDownloadCount(token string) (rec fileRecord, ok bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok = m.records[token]
+	if !ok {
+		return fileRecord{}, false, nil
+	}
+
+	rec.DownloadCount++
+	m.records[token] = rec
+	return rec, true, m.saveLocked()
+}
+
+// BlobRefCount returns how many records still reference blobName, used to
+// decide whether deleting a record may also delete its underlying blob.
+func (m *metadataStore) BlobRefCount(blobName string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, rec := range m.records {
+		if rec.blobName() == blobName {
+			count++
+		}
+	}
+	return count
+}
+
+// ReserveRecord looks up an existing record for rec's hash and extension
+// and inserts rec in the same critical section, so existed accurately
+// reflects whether rec's upload can dedup against an already-stored blob,
+// and by the time this returns rec itself already counts toward
+// BlobRefCount(rec.blobName()). Doing both under one lock closes the gap
+// between deciding to dedup and committing the new record: without it, the
+// last other token referencing that blob could expire and be swept by the
+// janitor in between, deleting the blob out from under the brand-new
+// token before it was ever persisted.
+func (m *metadataStore) ReserveRecord(rec fileRecord) (existed bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, other := range m.records {
+		if other.Hash == rec.Hash && other.Ext == rec.Ext {
+			existed = true
+			break
+		}
+	}
+
+	m.records[rec.Token] = rec
+	return existed, m.saveLocked()
+}
+
+// All returns a snapshot of every known record, used to rehydrate Server
+// state and to sweep for expired files.
+func (m *metadataStore) All() []fileRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	all := make([]fileRecord, 0, len(m.records))
+	for _, rec := range m.records {
+		all = append(all, rec)
+	}
+	return all
+}
+
+// saveLocked writes the store to a temp file and renames it into place so
+// a crash mid-write never leaves a truncated metadata file. Callers must
+// hold m.mu.
+func (m *metadataStore) saveLocked() error {
+	data, err := json.MarshalIndent(m.records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
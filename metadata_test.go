@@ -0,0 +1,114 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMetadataStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.json")
+
+	store, err := openMetadataStore(path)
+	if err != nil {
+		t.Fatalf("openMetadataStore: %v", err)
+	}
+
+	rec := fileRecord{
+		Token:      "tok1",
+		Hash:       "deadbeef",
+		Ext:        ".bin",
+		UploadedAt: time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+		Size:       42,
+	}
+	if err := store.Put(rec); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	reopened, err := openMetadataStore(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	got, ok := reopened.Get(rec.Token)
+	if !ok {
+		t.Fatalf("Get(%q) after reopen: not found", rec.Token)
+	}
+	if got.Hash != rec.Hash || got.Size != rec.Size {
+		t.Fatalf("Get(%q) after reopen = %+v, want %+v", rec.Token, got, rec)
+	}
+}
+
+func TestMetadataStoreOpenMissingFile(t *testing.T) {
+	store, err := openMetadataStore(filepath.Join(t.TempDir(), "does-not-exist.json"))
+	if err != nil {
+		t.Fatalf("openMetadataStore: %v", err)
+	}
+	if all := store.All(); len(all) != 0 {
+		t.Fatalf("All() on fresh store = %v, want empty", all)
+	}
+}
+
+func TestReserveRecordDetectsExistingHash(t *testing.T) {
+	store, err := openMetadataStore(filepath.Join(t.TempDir(), "metadata.json"))
+	if err != nil {
+		t.Fatalf("openMetadataStore: %v", err)
+	}
+
+	first := fileRecord{Token: "tok1", Hash: "abc", Ext: ".txt", ExpiresAt: time.Now().Add(time.Hour)}
+	if existed, err := store.ReserveRecord(first); err != nil || existed {
+		t.Fatalf("ReserveRecord(first) = (%v, %v), want (false, nil)", existed, err)
+	}
+
+	second := fileRecord{Token: "tok2", Hash: "abc", Ext: ".txt", ExpiresAt: time.Now().Add(time.Hour)}
+	existed, err := store.ReserveRecord(second)
+	if err != nil {
+		t.Fatalf("ReserveRecord(second): %v", err)
+	}
+	if !existed {
+		t.Fatalf("ReserveRecord(second) existed = false, want true")
+	}
+
+	// Both tokens are persisted, and both already count toward the shared
+	// blob's ref count, even though the second reserved before any Rename
+	// or Delete happened against storage.
+	if count := store.BlobRefCount(first.blobName()); count != 2 {
+		t.Fatalf("BlobRefCount = %d, want 2", count)
+	}
+}
+
+func TestIncrementDownloadCountUnknownToken(t *testing.T) {
+	store, err := openMetadataStore(filepath.Join(t.TempDir(), "metadata.json"))
+	if err != nil {
+		t.Fatalf("openMetadataStore: %v", err)
+	}
+
+	if _, ok, err := store.IncrementDownloadCount("missing"); ok || err != nil {
+		t.Fatalf("IncrementDownloadCount(missing) = (ok=%v, err=%v), want (false, nil)", ok, err)
+	}
+}
+
+func TestIncrementDownloadCount(t *testing.T) {
+	store, err := openMetadataStore(filepath.Join(t.TempDir(), "metadata.json"))
+	if err != nil {
+		t.Fatalf("openMetadataStore: %v", err)
+	}
+
+	rec := fileRecord{Token: "tok1", Hash: "abc", Ext: ".txt", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := store.Put(rec); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	updated, ok, err := store.IncrementDownloadCount(rec.Token)
+	if err != nil || !ok {
+		t.Fatalf("IncrementDownloadCount = (ok=%v, err=%v), want (true, nil)", ok, err)
+	}
+	if updated.DownloadCount != 1 {
+		t.Fatalf("DownloadCount = %d, want 1", updated.DownloadCount)
+	}
+
+	persisted, _ := store.Get(rec.Token)
+	if persisted.DownloadCount != 1 {
+		t.Fatalf("Get after increment DownloadCount = %d, want 1", persisted.DownloadCount)
+	}
+}
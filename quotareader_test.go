@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// TestQuotaReaderOverLimitErrorIsNotEOFSentinel guards against quotaReader
+// signaling an over-limit condition with io.EOF or io.ErrUnexpectedEOF:
+// io.ReadFull-based callers (the S3 and GCS Put loops) treat both of those
+// as a normal end of stream, not a failure, which would let an oversized or
+// over-quota upload's multipart/resumable upload get completed instead of
+// aborted.
+func TestQuotaReaderOverLimitErrorIsNotEOFSentinel(t *testing.T) {
+	s := &Server{StorageQuota: 1 << 30}
+	q := &quotaReader{r: bytes.NewReader([]byte("0123456789")), s: s, max: 4}
+
+	buf := make([]byte, 10)
+	_, err := q.Read(buf)
+	if err == nil {
+		t.Fatalf("Read over max: want an error, got nil")
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Read over max returned %v, want a sentinel distinct from io.EOF/io.ErrUnexpectedEOF", err)
+	}
+	if !errors.Is(err, errFileTooLarge) {
+		t.Fatalf("Read over max returned %v, want errFileTooLarge", err)
+	}
+	if q.err != errFileTooLarge {
+		t.Fatalf("q.err = %v, want errFileTooLarge", q.err)
+	}
+}
@@ -0,0 +1,12 @@
+package storage
+
+import "os"
+
+// envOr returns the value of the named environment variable, or fallback
+// if it is unset or empty.
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
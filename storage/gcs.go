@@ -0,0 +1,385 @@
+package storage
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// gcsTokenURL is Google's OAuth2 token endpoint used to exchange a signed
+// JWT for a short-lived access token (the standard service-account flow).
+const gcsTokenURL = "https://oauth2.googleapis.com/token"
+
+// gcsChunkSize is the per-request chunk size for resumable uploads. GCS
+// requires it be a multiple of 256 KiB; keeping it fixed bounds memory use
+// to one chunk regardless of the overall upload size.
+const gcsChunkSize = 8 << 20 // 8 MiB
+
+// GCS stores files as objects in a Google Cloud Storage bucket via the
+// JSON API, authenticating with a service account key instead of a
+// third-party SDK.
+type GCS struct {
+	bucket     string
+	email      string
+	privateKey *rsa.PrivateKey
+	client     *http.Client
+
+	mu       sync.Mutex
+	token    string
+	tokenExp time.Time
+}
+
+type gcsCredentials struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// NewGCSFromEnv builds a GCS backend from GCS_BUCKET and a service account
+// key file referenced by GCS_CREDENTIALS_FILE.
+func NewGCSFromEnv() (*GCS, error) {
+	bucket := envOr("GCS_BUCKET", "")
+	if bucket == "" {
+		return nil, errors.New("storage: GCS_BUCKET is required for the gcs backend")
+	}
+	credsPath := envOr("GCS_CREDENTIALS_FILE", "")
+	if credsPath == "" {
+		return nil, errors.New("storage: GCS_CREDENTIALS_FILE is required for the gcs backend")
+	}
+
+	raw, err := os.ReadFile(credsPath)
+	if err != nil {
+		return nil, fmt.Errorf("storage: reading GCS credentials: %w", err)
+	}
+
+	var creds gcsCredentials
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, fmt.Errorf("storage: parsing GCS credentials: %w", err)
+	}
+
+	key, err := parsePrivateKey(creds.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("storage: parsing GCS private key: %w", err)
+	}
+
+	return &GCS{
+		bucket:     bucket,
+		email:      creds.ClientEmail,
+		privateKey: key,
+		client:     &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Put uploads r as name using a resumable upload session, streaming
+// gcsChunkSize bytes at a time rather than buffering the whole body in
+// memory.
+func (g *GCS) Put(name string, r io.Reader) (int64, error) {
+	sessionURI, err := g.startResumableSession(name)
+	if err != nil {
+		return 0, err
+	}
+
+	var offset int64
+	buf := make([]byte, gcsChunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return 0, readErr
+		}
+		last := readErr == io.EOF || readErr == io.ErrUnexpectedEOF
+
+		if n > 0 || last {
+			if err := g.uploadChunk(sessionURI, buf[:n], offset, last); err != nil {
+				return 0, err
+			}
+			offset += int64(n)
+		}
+		if last {
+			break
+		}
+	}
+
+	return offset, nil
+}
+
+// startResumableSession initiates a resumable upload and returns the
+// session URI that subsequent chunk PUTs target.
+func (g *GCS) startResumableSession(name string) (string, error) {
+	u := fmt.Sprintf("https://storage.googleapis.com/upload/storage/v1/b/%s/o?uploadType=resumable&name=%s",
+		g.bucket, url.QueryEscape(name))
+
+	token, err := g.accessToken()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("storage: gcs start resumable upload %s: %s", name, resp.Status)
+	}
+
+	sessionURI := resp.Header.Get("Location")
+	if sessionURI == "" {
+		return "", errors.New("storage: gcs resumable upload did not return a session URI")
+	}
+	return sessionURI, nil
+}
+
+// uploadChunk PUTs one chunk of a resumable upload session starting at
+// offset. last marks the final chunk, whose Content-Range states the
+// total size so GCS finalizes the object; GCS replies 308 ("Resume
+// Incomplete") to every non-final chunk.
+func (g *GCS) uploadChunk(sessionURI string, chunk []byte, offset int64, last bool) error {
+	rangeTotal := "*"
+	if last {
+		rangeTotal = fmt.Sprintf("%d", offset+int64(len(chunk)))
+	}
+
+	contentRange := fmt.Sprintf("bytes %d-%d/%s", offset, offset+int64(len(chunk))-1, rangeTotal)
+	if len(chunk) == 0 {
+		contentRange = fmt.Sprintf("bytes */%s", rangeTotal)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, sessionURI, bytes.NewReader(chunk))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Range", contentRange)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case last && resp.StatusCode == http.StatusOK:
+		return nil
+	case !last && resp.StatusCode == http.StatusPermanentRedirect:
+		return nil
+	default:
+		return fmt.Errorf("storage: gcs upload chunk at offset %d: %s", offset, resp.Status)
+	}
+}
+
+func (g *GCS) Get(name string) (io.ReadCloser, int64, error) {
+	u := fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o/%s?alt=media",
+		g.bucket, url.PathEscape(name))
+	resp, err := g.doAuthed(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, 0, ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("storage: gcs get %s: %s", name, resp.Status)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+func (g *GCS) Delete(name string) error {
+	u := fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o/%s", g.bucket, url.PathEscape(name))
+	resp, err := g.doAuthed(http.MethodDelete, u, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotExist
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("storage: gcs delete %s: %s", name, resp.Status)
+	}
+	return nil
+}
+
+// Rename copies oldName to newName with a server-side rewrite and then
+// deletes oldName.
+func (g *GCS) Rename(oldName, newName string) error {
+	u := fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o/%s/rewriteTo/b/%s/o/%s",
+		g.bucket, url.PathEscape(oldName), g.bucket, url.PathEscape(newName))
+	resp, err := g.doAuthed(http.MethodPost, u, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("storage: gcs rename %s -> %s: %s", oldName, newName, resp.Status)
+	}
+
+	return g.Delete(oldName)
+}
+
+func (g *GCS) Stat(name string) (Info, error) {
+	u := fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o/%s", g.bucket, url.PathEscape(name))
+	resp, err := g.doAuthed(http.MethodGet, u, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Info{}, ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Info{}, fmt.Errorf("storage: gcs stat %s: %s", name, resp.Status)
+	}
+
+	var meta struct {
+		Size    string    `json:"size"`
+		Updated time.Time `json:"updated"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return Info{}, err
+	}
+
+	var size int64
+	fmt.Sscanf(meta.Size, "%d", &size)
+	return Info{Name: name, Size: size, ModTime: meta.Updated}, nil
+}
+
+func (g *GCS) doAuthed(method, u string, body io.Reader) (*http.Response, error) {
+	token, err := g.accessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return g.client.Do(req)
+}
+
+// accessToken returns a cached OAuth2 access token, refreshing it via the
+// JWT bearer flow (RFC 7523) when it is missing or about to expire.
+func (g *GCS) accessToken() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.token != "" && time.Now().Before(g.tokenExp) {
+		return g.token, nil
+	}
+
+	assertion, err := g.signedJWT()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	resp, err := g.client.PostForm(gcsTokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("storage: gcs token exchange: %s", resp.Status)
+	}
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", err
+	}
+
+	g.token = tok.AccessToken
+	g.tokenExp = time.Now().Add(time.Duration(tok.ExpiresIn-30) * time.Second)
+	return g.token, nil
+}
+
+func (g *GCS) signedJWT() (string, error) {
+	now := time.Now().Unix()
+	header := base64url(mustJSON(map[string]string{"alg": "RS256", "typ": "JWT"}))
+	claims := base64url(mustJSON(map[string]interface{}{
+		"iss":   g.email,
+		"scope": "https://www.googleapis.com/auth/devstorage.read_write",
+		"aud":   gcsTokenURL,
+		"iat":   now,
+		"exp":   now + 3600,
+	}))
+
+	signingInput := header + "." + claims
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, g.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func base64url(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func mustJSON(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func parsePrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not RSA")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Local stores files as-is on the local filesystem, rooted at path.
+type Local struct {
+	path string
+}
+
+// NewLocal creates a Local backend rooted at path, creating the directory
+// if it does not already exist.
+func NewLocal(path string) (*Local, error) {
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &Local{path: path}, nil
+}
+
+func (l *Local) Put(name string, r io.Reader) (int64, error) {
+	out, err := os.Create(filepath.Join(l.path, name))
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	return io.Copy(out, r)
+}
+
+func (l *Local) Get(name string) (io.ReadCloser, int64, error) {
+	f, err := os.Open(filepath.Join(l.path, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, ErrNotExist
+		}
+		return nil, 0, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, stat.Size(), nil
+}
+
+func (l *Local) Delete(name string) error {
+	err := os.Remove(filepath.Join(l.path, name))
+	if os.IsNotExist(err) {
+		return ErrNotExist
+	}
+	return err
+}
+
+func (l *Local) Rename(oldName, newName string) error {
+	err := os.Rename(filepath.Join(l.path, oldName), filepath.Join(l.path, newName))
+	if os.IsNotExist(err) {
+		return ErrNotExist
+	}
+	return err
+}
+
+func (l *Local) Stat(name string) (Info, error) {
+	stat, err := os.Stat(filepath.Join(l.path, name))
+	if os.IsNotExist(err) {
+		return Info{}, ErrNotExist
+	}
+	if err != nil {
+		return Info{}, err
+	}
+
+	return Info{Name: name, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
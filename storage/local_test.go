@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalPutGetRoundTrip(t *testing.T) {
+	l, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	want := []byte("hello, simplehost")
+	n, err := l.Put("greeting.txt", bytes.NewReader(want))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if n != int64(len(want)) {
+		t.Fatalf("Put returned %d bytes, want %d", n, len(want))
+	}
+
+	r, size, err := l.Get("greeting.txt")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer r.Close()
+
+	if size != int64(len(want)) {
+		t.Fatalf("Get size = %d, want %d", size, len(want))
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Get content = %q, want %q", got, want)
+	}
+}
+
+func TestLocalGetMissing(t *testing.T) {
+	l, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	if _, _, err := l.Get("missing"); err != ErrNotExist {
+		t.Fatalf("Get missing = %v, want ErrNotExist", err)
+	}
+}
+
+func TestLocalDeleteMissing(t *testing.T) {
+	l, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	if err := l.Delete("missing"); err != ErrNotExist {
+		t.Fatalf("Delete missing = %v, want ErrNotExist", err)
+	}
+}
+
+func TestLocalRename(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLocal(dir)
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	if _, err := l.Put("old", bytes.NewReader([]byte("payload"))); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := l.Rename("old", "new"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	if _, _, err := l.Get("old"); err != ErrNotExist {
+		t.Fatalf("Get old name after rename = %v, want ErrNotExist", err)
+	}
+	if _, err := l.Stat("new"); err != nil {
+		t.Fatalf("Stat new name: %v", err)
+	}
+
+	if err := l.Rename("still-missing", filepath.Base("x")); err != ErrNotExist {
+		t.Fatalf("Rename missing source = %v, want ErrNotExist", err)
+	}
+}
@@ -0,0 +1,346 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// s3PartSize is the chunk size used for multipart uploads. Buffering one
+// part at a time (instead of io.ReadAll-ing the whole body) keeps memory
+// use bounded regardless of the overall upload size.
+const s3PartSize = 8 << 20 // 8 MiB
+
+// S3 stores files in an S3-compatible bucket (AWS S3, MinIO, ...) using
+// SigV4 request signing. It speaks plain HTTP(S) against the REST API so
+// SimpleHost has no third-party SDK dependency.
+type S3 struct {
+	endpoint  string // e.g. https://s3.amazonaws.com or http://minio:9000
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// NewS3FromEnv builds an S3 backend from S3_ENDPOINT, S3_BUCKET,
+// S3_REGION, S3_ACCESS_KEY and S3_SECRET_KEY environment variables.
+func NewS3FromEnv() (*S3, error) {
+	bucket := envOr("S3_BUCKET", "")
+	if bucket == "" {
+		return nil, errors.New("storage: S3_BUCKET is required for the s3 backend")
+	}
+	accessKey := envOr("S3_ACCESS_KEY", "")
+	secretKey := envOr("S3_SECRET_KEY", "")
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("storage: S3_ACCESS_KEY and S3_SECRET_KEY are required for the s3 backend")
+	}
+
+	return &S3{
+		endpoint:  strings.TrimRight(envOr("S3_ENDPOINT", "https://s3.amazonaws.com"), "/"),
+		bucket:    bucket,
+		region:    envOr("S3_REGION", "us-east-1"),
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *S3) objectURL(name string, query url.Values) string {
+	u := fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, url.PathEscape(name))
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+func (s *S3) do(method, name string, query url.Values, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(name, query), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body)
+
+	return s.client.Do(req)
+}
+
+// Put uploads r as name using a multipart upload, streaming one
+// s3PartSize chunk at a time rather than buffering the whole body, so a
+// multi-gigabyte upload doesn't need to be held in memory at once.
+func (s *S3) Put(name string, r io.Reader) (int64, error) {
+	uploadID, err := s.createMultipartUpload(name)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		parts []s3CompletedPart
+		total int64
+		buf   = make([]byte, s3PartSize)
+	)
+	for partNumber := 1; ; partNumber++ {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			s.abortMultipartUpload(name, uploadID)
+			return 0, readErr
+		}
+		last := readErr == io.EOF || readErr == io.ErrUnexpectedEOF
+
+		if n > 0 {
+			etag, err := s.uploadPart(name, uploadID, partNumber, buf[:n])
+			if err != nil {
+				s.abortMultipartUpload(name, uploadID)
+				return 0, err
+			}
+			parts = append(parts, s3CompletedPart{PartNumber: partNumber, ETag: etag})
+			total += int64(n)
+		}
+		if last {
+			break
+		}
+	}
+
+	if len(parts) == 0 {
+		// S3 requires at least one part, even for a zero-byte upload.
+		etag, err := s.uploadPart(name, uploadID, 1, nil)
+		if err != nil {
+			s.abortMultipartUpload(name, uploadID)
+			return 0, err
+		}
+		parts = append(parts, s3CompletedPart{PartNumber: 1, ETag: etag})
+	}
+
+	if err := s.completeMultipartUpload(name, uploadID, parts); err != nil {
+		s.abortMultipartUpload(name, uploadID)
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// s3CompletedPart records one uploaded part's number and ETag, as required
+// by the CompleteMultipartUpload request body.
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+func (s *S3) createMultipartUpload(name string) (string, error) {
+	resp, err := s.do(http.MethodPost, name, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("storage: s3 create multipart upload %s: %s", name, resp.Status)
+	}
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.UploadID, nil
+}
+
+func (s *S3) uploadPart(name, uploadID string, partNumber int, data []byte) (string, error) {
+	query := url.Values{
+		"partNumber": {fmt.Sprintf("%d", partNumber)},
+		"uploadId":   {uploadID},
+	}
+	resp, err := s.do(http.MethodPut, name, query, data)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("storage: s3 upload part %d of %s: %s", partNumber, name, resp.Status)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+func (s *S3) completeMultipartUpload(name, uploadID string, parts []s3CompletedPart) error {
+	body, err := xml.Marshal(s3CompleteMultipartUpload{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodPost, name, url.Values{"uploadId": {uploadID}}, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("storage: s3 complete multipart upload %s: %s", name, resp.Status)
+	}
+	return nil
+}
+
+// abortMultipartUpload releases an in-progress upload's parts after a
+// failure partway through. Best-effort: a failed abort just leaves the
+// incomplete upload for the bucket's lifecycle rules to clean up.
+func (s *S3) abortMultipartUpload(name, uploadID string) {
+	resp, err := s.do(http.MethodDelete, name, url.Values{"uploadId": {uploadID}}, nil)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+func (s *S3) Get(name string) (io.ReadCloser, int64, error) {
+	resp, err := s.do(http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, 0, ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("storage: s3 get %s: %s", name, resp.Status)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+// Rename copies oldName to newName with a server-side COPY (no data
+// round-trips through SimpleHost) and then deletes oldName.
+func (s *S3) Rename(oldName, newName string) error {
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(newName, nil), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Amz-Copy-Source", fmt.Sprintf("/%s/%s", s.bucket, url.PathEscape(oldName)))
+	s.sign(req, nil)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("storage: s3 rename %s -> %s: %s", oldName, newName, resp.Status)
+	}
+
+	return s.Delete(oldName)
+}
+
+func (s *S3) Delete(name string) error {
+	// S3 answers a DELETE of a missing key with 204, so check existence
+	// first to report ErrNotExist like the other backends.
+	if _, err := s.Stat(name); err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodDelete, name, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("storage: s3 delete %s: %s", name, resp.Status)
+	}
+	return nil
+}
+
+func (s *S3) Stat(name string) (Info, error) {
+	resp, err := s.do(http.MethodHead, name, nil, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Info{}, ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Info{}, fmt.Errorf("storage: s3 stat %s: %s", name, resp.Status)
+	}
+
+	modTime, _ := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
+	return Info{Name: name, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+// sign adds the AWS Signature Version 4 headers required by S3-compatible
+// APIs. See https://docs.aws.amazon.com/general/latest/gr/sigv4-signing.html.
+func (s *S3) sign(req *http.Request, body []byte) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("Host", req.URL.Host)
+
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n",
+		req.URL.Host, payloadHash, amzDate)
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	credentialScope := fmt.Sprintf("%s/%s/s3/aws4_request", dateStamp, s.region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		credentialScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := s.signingKey(dateStamp)
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	authHeader := fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, credentialScope, signedHeaders, signature)
+	req.Header.Set("Authorization", authHeader)
+}
+
+func (s *S3) signingKey(dateStamp string) []byte {
+	kDate := hmacSHA256([]byte("AWS4"+s.secretKey), dateStamp)
+	kRegion := hmacSHA256(kDate, s.region)
+	kService := hmacSHA256(kRegion, "s3")
+	return hmacSHA256(kService, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func testS3() *S3 {
+	return &S3{
+		endpoint:  "https://s3.amazonaws.com",
+		bucket:    "my-bucket",
+		region:    "us-east-1",
+		accessKey: "AKIAEXAMPLE",
+		secretKey: "secretkeyexample",
+		client:    http.DefaultClient,
+	}
+}
+
+func TestS3SignSetsPayloadHash(t *testing.T) {
+	s := testS3()
+	body := []byte("upload body")
+
+	req, err := http.NewRequest(http.MethodPut, s.objectURL("blob.bin", nil), nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	s.sign(req, body)
+
+	want := sha256Hex(body)
+	if got := req.Header.Get("X-Amz-Content-Sha256"); got != want {
+		t.Fatalf("X-Amz-Content-Sha256 = %q, want %q", got, want)
+	}
+	if req.Header.Get("Host") != req.URL.Host {
+		t.Fatalf("Host header = %q, want %q", req.Header.Get("Host"), req.URL.Host)
+	}
+	if !regexp.MustCompile(`^\d{8}T\d{6}Z$`).MatchString(req.Header.Get("X-Amz-Date")) {
+		t.Fatalf("X-Amz-Date = %q, does not look like ISO8601 basic format", req.Header.Get("X-Amz-Date"))
+	}
+}
+
+func TestS3SignAuthorizationHeader(t *testing.T) {
+	s := testS3()
+
+	req, err := http.NewRequest(http.MethodGet, s.objectURL("blob.bin", nil), nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	s.sign(req, nil)
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/") {
+		t.Fatalf("Authorization = %q, missing expected credential prefix", auth)
+	}
+	if !strings.Contains(auth, "/"+s.region+"/s3/aws4_request") {
+		t.Fatalf("Authorization = %q, missing credential scope for region/service", auth)
+	}
+	if !strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date") {
+		t.Fatalf("Authorization = %q, missing expected SignedHeaders", auth)
+	}
+	if !regexp.MustCompile(`Signature=[0-9a-f]{64}$`).MatchString(auth) {
+		t.Fatalf("Authorization = %q, missing a hex-encoded signature", auth)
+	}
+}
+
+func TestS3ObjectURLIncludesQuery(t *testing.T) {
+	s := testS3()
+
+	got := s.objectURL("blob.bin", map[string][]string{"uploadId": {"abc123"}})
+	want := "https://s3.amazonaws.com/my-bucket/blob.bin?uploadId=abc123"
+	if got != want {
+		t.Fatalf("objectURL = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+// Package storage defines the pluggable backend that SimpleHost stores
+// uploaded files in, along with a local filesystem implementation.
+package storage
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotExist is returned by Get, Stat and Delete when the named file does
+// not exist in the backend.
+var ErrNotExist = errors.New("storage: file does not exist")
+
+// Info describes a stored file.
+type Info struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage is implemented by every backend SimpleHost can store uploads in
+// (local disk, S3-compatible object storage, Google Cloud Storage, ...).
+// Implementations must be safe for concurrent use.
+type Storage interface {
+	// Put writes r to name, creating or overwriting it. It returns the
+	// number of bytes written.
+	Put(name string, r io.Reader) (int64, error)
+
+	// Get opens name for reading. Callers must close the returned
+	// ReadCloser. The second return value is the file size in bytes.
+	Get(name string) (io.ReadCloser, int64, error)
+
+	// Delete removes name. It returns ErrNotExist if name is not present.
+	Delete(name string) error
+
+	// Stat returns metadata about name without reading its contents.
+	Stat(name string) (Info, error)
+
+	// Rename moves oldName to newName, overwriting newName if it already
+	// exists. It returns ErrNotExist if oldName is not present.
+	Rename(oldName, newName string) error
+}
+
+// NewFromEnv builds the Storage backend selected by the STORAGE_BACKEND
+// environment variable ("local", "s3" or "gcs"), defaulting to "local"
+// when unset. It is the entry point main.go uses to wire the Server up.
+func NewFromEnv() (Storage, error) {
+	backend := envOr("STORAGE_BACKEND", "local")
+
+	switch backend {
+	case "local":
+		path := envOr("STORAGE_PATH", "./uploads")
+		return NewLocal(path)
+	case "s3":
+		return NewS3FromEnv()
+	case "gcs":
+		return NewGCSFromEnv()
+	default:
+		return nil, errors.New("storage: unknown STORAGE_BACKEND " + backend)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestStoreUploadRenamesOwnBlobEvenWhenDuplicate guards against the race
+// where an uploader that reserves a hash+ext first never actually
+// materializes the blob (its own Rename fails, or is simply still in
+// flight) before a second, racing uploader with identical content sees
+// isDuplicate=true from ReserveRecord. The second uploader must not assume
+// the first upload's Rename will succeed and skip its own — otherwise its
+// token ends up pointing at a blob that never gets created.
+func TestStoreUploadRenamesOwnBlobEvenWhenDuplicate(t *testing.T) {
+	meta, err := openMetadataStore(t.TempDir() + "/metadata.json")
+	if err != nil {
+		t.Fatalf("openMetadataStore: %v", err)
+	}
+	store := newFakeStorage()
+	s := NewServer(store, meta)
+
+	content := []byte("identical content for both uploads")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	// Simulate the first uploader: it reserved the hash+ext but its own
+	// Rename never happened (e.g. it's about to fail), so no blob exists
+	// under hash+ext yet.
+	firstRec := fileRecord{
+		Token:     "first-token",
+		Hash:      hash,
+		Ext:       ".txt",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if existed, err := meta.ReserveRecord(firstRec); err != nil || existed {
+		t.Fatalf("ReserveRecord(first) = (%v, %v), want (false, nil)", existed, err)
+	}
+
+	token, err := s.storeUpload(bytes.NewReader(content), ".txt", time.Hour, 0, "")
+	if err != nil {
+		t.Fatalf("storeUpload: %v", err)
+	}
+
+	rec, ok := meta.Get(token)
+	if !ok {
+		t.Fatalf("token %q not found in metadata", token)
+	}
+
+	rc, _, err := store.Get(rec.blobName())
+	if err != nil {
+		t.Fatalf("blob %q was never materialized despite ReserveRecord reporting a duplicate: %v", rec.blobName(), err)
+	}
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("reading blob: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("blob content = %q, want %q", got, content)
+	}
+}